@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// boltBackend is the original skv behavior: a single local BoltDB file.
+// Because of BoltDB restrictions, only one process may open the file at a
+// time; attempts to open the file from another process fail with a timeout
+// error.
+//
+// db is swapped out from under in-flight callers by Restore, so every
+// access goes through mu rather than reading the field directly.
+type boltBackend struct {
+	mu   sync.RWMutex
+	db   *bolt.DB
+	path string
+}
+
+// withDB runs fn against the current *bolt.DB, holding mu's read lock for
+// fn's entire duration - not just long enough to read the field - so a
+// concurrent Restore cannot close or swap b.db until fn returns.
+func (b *boltBackend) withDB(fn func(*bolt.DB) error) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return fn(b.db)
+}
+
+func newBoltBackend(u *url.URL) (Backend, error) {
+	path := u.Path
+	if path == "" {
+		// Support the legacy "bolt:relative/path" form in addition to
+		// "bolt:///absolute/path".
+		path = u.Opaque
+	}
+
+	db, err := bolt.Open(path, 0640, &bolt.Options{
+		Timeout: 50 * time.Millisecond,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &boltBackend{db: db, path: path}, nil
+}
+
+func (b *boltBackend) AddBucket(bucket string) error {
+	return b.withDB(func(db *bolt.DB) error {
+		return db.Update(func(tx *bolt.Tx) error {
+			_, err := tx.CreateBucketIfNotExists([]byte(bucket))
+			return err
+		})
+	})
+}
+
+func (b *boltBackend) Put(bucket, key string, value []byte) error {
+	if err := b.AddBucket(bucket); err != nil {
+		return err
+	}
+
+	return b.withDB(func(db *bolt.DB) error {
+		return db.Update(func(tx *bolt.Tx) error {
+			return tx.Bucket([]byte(bucket)).Put([]byte(key), value)
+		})
+	})
+}
+
+func (b *boltBackend) Get(bucket, key string) ([]byte, error) {
+	var value []byte
+
+	err := b.withDB(func(db *bolt.DB) error {
+		return db.View(func(tx *bolt.Tx) error {
+			bkt := tx.Bucket([]byte(bucket))
+			if bkt == nil {
+				return ErrNotFound
+			}
+
+			c := bkt.Cursor()
+			k, v := c.Seek([]byte(key))
+			if k == nil || string(k) != key {
+				return ErrNotFound
+			}
+			if v != nil {
+				value = append([]byte(nil), v...)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+func (b *boltBackend) Delete(bucket, key string) error {
+	return b.withDB(func(db *bolt.DB) error {
+		return db.Update(func(tx *bolt.Tx) error {
+			bkt := tx.Bucket([]byte(bucket))
+			if bkt == nil {
+				return ErrNotFound
+			}
+
+			c := bkt.Cursor()
+			if k, _ := c.Seek([]byte(key)); k == nil || string(k) != key {
+				return ErrNotFound
+			}
+			return c.Delete()
+		})
+	})
+}
+
+func (b *boltBackend) Scan(bucket string) ([]Entry, error) {
+	var entries []Entry
+
+	err := b.withDB(func(db *bolt.DB) error {
+		return db.View(func(tx *bolt.Tx) error {
+			bkt := tx.Bucket([]byte(bucket))
+			if bkt == nil {
+				return nil
+			}
+
+			return bkt.ForEach(func(k, v []byte) error {
+				entries = append(entries, Entry{
+					Key:   string(k),
+					Value: append([]byte(nil), v...),
+				})
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// Update runs fn under a single bolt.Tx, so every Get/Put/Delete/ForEach
+// call fn makes commits (or rolls back) together.
+func (b *boltBackend) Update(fn func(Txn) error) error {
+	return b.withDB(func(db *bolt.DB) error {
+		return db.Update(func(tx *bolt.Tx) error {
+			return fn(&boltTxn{tx: tx})
+		})
+	})
+}
+
+// View runs fn under a single read-only bolt.Tx.
+func (b *boltBackend) View(fn func(Txn) error) error {
+	return b.withDB(func(db *bolt.DB) error {
+		return db.View(func(tx *bolt.Tx) error {
+			return fn(&boltTxn{tx: tx})
+		})
+	})
+}
+
+// Backup writes an atomic hot snapshot of the whole database to w, using
+// bolt's own Tx.WriteTo.
+func (b *boltBackend) Backup(w io.Writer) error {
+	return b.withDB(func(db *bolt.DB) error {
+		return db.View(func(tx *bolt.Tx) error {
+			_, err := tx.WriteTo(w)
+			return err
+		})
+	})
+}
+
+// Restore replaces the database file with the snapshot read from r, which
+// must have been produced by Backup. The backend briefly closes and
+// reopens its BoltDB file to do so.
+//
+// The close-rename-reopen sequence runs under mu's write lock so no
+// concurrent Get/Put/etc. can observe b.db mid-swap, and failures after the
+// close fall back to reopening the still-intact pre-restore file instead of
+// leaving b.db pointing at a closed handle.
+func (b *boltBackend) Restore(r io.Reader) error {
+	tmp := b.path + ".restore"
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.db.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, b.path); err != nil {
+		// The pre-restore file is still at b.path untouched; reopen it so
+		// the backend keeps serving the old (still valid) data instead of
+		// being left on a closed handle.
+		if db, reopenErr := bolt.Open(b.path, 0640, &bolt.Options{Timeout: 50 * time.Millisecond}); reopenErr == nil {
+			b.db = db
+		}
+		return err
+	}
+
+	db, err := bolt.Open(b.path, 0640, &bolt.Options{
+		Timeout: 50 * time.Millisecond,
+	})
+	if err != nil {
+		// b.path now holds the restored snapshot but we couldn't open it;
+		// there is no previous file left to fall back to, so leave b.db
+		// nil rather than a closed handle - callers fail fast instead of
+		// silently hitting "database not open" forever.
+		b.db = nil
+		return err
+	}
+	b.db = db
+
+	return nil
+}
+
+func (b *boltBackend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.db.Close()
+}
+
+// boltTxn implements Txn on top of a single bolt.Tx, mirroring KVStore's
+// gob encode/decode convention so callers see the same Get/Put semantics
+// inside an Update/View as they do outside one.
+type boltTxn struct {
+	tx *bolt.Tx
+}
+
+func (t *boltTxn) Get(bucket, key string, value interface{}) error {
+	bkt := t.tx.Bucket([]byte(bucket))
+	if bkt == nil {
+		return ErrNotFound
+	}
+
+	c := bkt.Cursor()
+	k, v := c.Seek([]byte(key))
+	if k == nil || string(k) != key {
+		return ErrNotFound
+	}
+	if v == nil || value == nil {
+		return nil
+	}
+
+	return gob.NewDecoder(bytes.NewReader(v)).Decode(value)
+}
+
+func (t *boltTxn) Put(bucket, key string, value interface{}) error {
+	if value == nil {
+		return ErrBadValue
+	}
+
+	bkt, err := t.tx.CreateBucketIfNotExists([]byte(bucket))
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+		return err
+	}
+
+	return bkt.Put([]byte(key), buf.Bytes())
+}
+
+func (t *boltTxn) Delete(bucket, key string) error {
+	bkt := t.tx.Bucket([]byte(bucket))
+	if bkt == nil {
+		return ErrNotFound
+	}
+
+	c := bkt.Cursor()
+	if k, _ := c.Seek([]byte(key)); k == nil || string(k) != key {
+		return ErrNotFound
+	}
+
+	return c.Delete()
+}
+
+func (t *boltTxn) ForEach(bucket string, fn func(key string, value []byte) error) error {
+	bkt := t.tx.Bucket([]byte(bucket))
+	if bkt == nil {
+		return nil
+	}
+
+	return bkt.ForEach(func(k, v []byte) error {
+		return fn(string(k), v)
+	})
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io"
+	"net/url"
+
+	"github.com/go-redis/redis"
+)
+
+// redisBackend stores each bucket as a Redis hash, with entry keys as hash
+// fields. This keeps Scan (HGETALL) and per-key Get/Put/Delete (HGET/HSET/
+// HDEL) both cheap single-command operations.
+type redisBackend struct {
+	client *redis.Client
+}
+
+func newRedisBackend(u *url.URL) (Backend, error) {
+	opts, err := redis.ParseURL(u.String())
+	if err != nil {
+		return nil, err
+	}
+
+	client := redis.NewClient(opts)
+	if err := client.Ping().Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &redisBackend{client: client}, nil
+}
+
+// AddBucket is a no-op for redis: the hash backing a bucket springs into
+// existence on the first HSET.
+func (b *redisBackend) AddBucket(bucket string) error {
+	return nil
+}
+
+func (b *redisBackend) Put(bucket, key string, value []byte) error {
+	return b.client.HSet(bucket, key, value).Err()
+}
+
+func (b *redisBackend) Get(bucket, key string) ([]byte, error) {
+	v, err := b.client.HGet(bucket, key).Bytes()
+	if err == redis.Nil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+func (b *redisBackend) Delete(bucket, key string) error {
+	n, err := b.client.HDel(bucket, key).Result()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (b *redisBackend) Scan(bucket string) ([]Entry, error) {
+	m, err := b.client.HGetAll(bucket).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(m))
+	for k, v := range m {
+		entries = append(entries, Entry{Key: k, Value: []byte(v)})
+	}
+
+	return entries, nil
+}
+
+// Update and View run fn against the live client directly: Redis has no
+// equivalent of a multi-key ACID transaction across arbitrary HSET/HDEL
+// commands, so unlike the Bolt and SQL backends this is best-effort
+// isolation, not a real guarantee.
+func (b *redisBackend) Update(fn func(Txn) error) error {
+	return fn(&redisTxn{client: b.client})
+}
+
+func (b *redisBackend) View(fn func(Txn) error) error {
+	return fn(&redisTxn{client: b.client})
+}
+
+// redisSnapshot is the gob wire format used by Backup/Restore: every
+// bucket's hash, keyed by bucket name.
+type redisSnapshot map[string]map[string][]byte
+
+// Backup walks every key in the keyspace, treating each as a bucket hash,
+// and gob-encodes the result to w.
+func (b *redisBackend) Backup(w io.Writer) error {
+	keys, err := b.client.Keys("*").Result()
+	if err != nil {
+		return err
+	}
+
+	snapshot := make(redisSnapshot, len(keys))
+	for _, bucket := range keys {
+		m, err := b.client.HGetAll(bucket).Result()
+		if err != nil {
+			return err
+		}
+
+		entries := make(map[string][]byte, len(m))
+		for k, v := range m {
+			entries[k] = []byte(v)
+		}
+		snapshot[bucket] = entries
+	}
+
+	return gob.NewEncoder(w).Encode(snapshot)
+}
+
+// Restore replaces every bucket in snapshot read from r, which must have
+// been produced by Backup.
+func (b *redisBackend) Restore(r io.Reader) error {
+	var snapshot redisSnapshot
+	if err := gob.NewDecoder(r).Decode(&snapshot); err != nil {
+		return err
+	}
+
+	for bucket, entries := range snapshot {
+		if err := b.client.Del(bucket).Err(); err != nil {
+			return err
+		}
+		for key, value := range entries {
+			if err := b.client.HSet(bucket, key, value).Err(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (b *redisBackend) Close() error {
+	return b.client.Close()
+}
+
+// redisTxn implements Txn directly against the Redis client, mirroring
+// KVStore's gob encode/decode convention. See the caveat on Update/View
+// above: this is not a real transaction.
+type redisTxn struct {
+	client *redis.Client
+}
+
+func (t *redisTxn) Get(bucket, key string, value interface{}) error {
+	v, err := t.client.HGet(bucket, key).Bytes()
+	if err == redis.Nil {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if v == nil || value == nil {
+		return nil
+	}
+
+	return gob.NewDecoder(bytes.NewReader(v)).Decode(value)
+}
+
+func (t *redisTxn) Put(bucket, key string, value interface{}) error {
+	if value == nil {
+		return ErrBadValue
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+		return err
+	}
+
+	return t.client.HSet(bucket, key, buf.Bytes()).Err()
+}
+
+func (t *redisTxn) Delete(bucket, key string) error {
+	n, err := t.client.HDel(bucket, key).Result()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (t *redisTxn) ForEach(bucket string, fn func(key string, value []byte) error) error {
+	m, err := t.client.HGetAll(bucket).Result()
+	if err != nil {
+		return err
+	}
+
+	for k, v := range m {
+		if err := fn(k, []byte(v)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"net/url"
+	"os"
+	"testing"
+)
+
+// newTestRedisBackend opens a redisBackend against the server named by the
+// SCRAPE_TEST_REDIS_URL environment variable (e.g. "redis://127.0.0.1:6379/15"),
+// skipping the test when it isn't set: unlike Bolt/SQL, redisBackend has no
+// embeddable in-process mode, so exercising it for real needs a running
+// server.
+func newTestRedisBackend(t *testing.T) *redisBackend {
+	t.Helper()
+
+	addr := os.Getenv("SCRAPE_TEST_REDIS_URL")
+	if addr == "" {
+		t.Skip("SCRAPE_TEST_REDIS_URL not set, skipping redisBackend test")
+	}
+
+	u, err := url.Parse(addr)
+	if err != nil {
+		t.Fatalf("parse SCRAPE_TEST_REDIS_URL: %v", err)
+	}
+
+	backend, err := newRedisBackend(u)
+	if err != nil {
+		t.Fatalf("newRedisBackend: %v", err)
+	}
+
+	b := backend.(*redisBackend)
+	t.Cleanup(func() {
+		b.client.Del("bucket")
+		b.Close()
+	})
+
+	return b
+}
+
+func TestRedisBackendPutGetDelete(t *testing.T) {
+	b := newTestRedisBackend(t)
+
+	if err := b.Put("bucket", "key", []byte("value")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	v, err := b.Get("bucket", "key")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if string(v) != "value" {
+		t.Fatalf("Get: got %q, want %q", v, "value")
+	}
+
+	if err := b.Delete("bucket", "key"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := b.Get("bucket", "key"); err != ErrNotFound {
+		t.Fatalf("Get after Delete: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestRedisBackendBackupRestoreRoundTrip(t *testing.T) {
+	b := newTestRedisBackend(t)
+
+	if err := b.Put("bucket", "key", []byte("value")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := b.Backup(&buf); err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+
+	if err := b.Put("bucket", "key", []byte("overwritten")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	if err := b.Restore(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	v, err := b.Get("bucket", "key")
+	if err != nil {
+		t.Fatalf("Get after Restore: %v", err)
+	}
+	if string(v) != "value" {
+		t.Fatalf("expected restored value %q, got %q", "value", v)
+	}
+}
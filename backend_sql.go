@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+// sqlBackend implements Backend on top of database/sql, storing entries as
+// (bucket, key, value, updated_at) rows keyed on (bucket, key). It is
+// shared by the sqlite and postgres backends, which differ only in driver
+// name, DSN, and placeholder/upsert syntax.
+type sqlBackend struct {
+	db *sql.DB
+
+	// placeholder returns the bind-parameter marker for the n'th (1-based)
+	// argument of a query, e.g. "?" for sqlite or "$1" for postgres.
+	placeholder func(n int) string
+}
+
+func openSQLBackend(driver, dsn string, placeholder func(n int) string) (*sqlBackend, error) {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	b := &sqlBackend{db: db, placeholder: placeholder}
+	if err := b.createTable(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func (b *sqlBackend) createTable() error {
+	_, err := b.db.Exec(`
+		CREATE TABLE IF NOT EXISTS kv (
+			bucket     TEXT NOT NULL,
+			key        TEXT NOT NULL,
+			value      BLOB NOT NULL,
+			updated_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (bucket, key)
+		)
+	`)
+	return err
+}
+
+// AddBucket is a no-op for SQL backends: bucket is just a column on the
+// shared kv table, so there is nothing to create ahead of time.
+func (b *sqlBackend) AddBucket(bucket string) error {
+	return nil
+}
+
+func (b *sqlBackend) Put(bucket, key string, value []byte) error {
+	q := fmt.Sprintf(`
+		INSERT INTO kv (bucket, key, value, updated_at)
+		VALUES (%s, %s, %s, %s)
+		ON CONFLICT (bucket, key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at
+	`, b.placeholder(1), b.placeholder(2), b.placeholder(3), b.placeholder(4))
+
+	_, err := b.db.Exec(q, bucket, key, value, time.Now())
+	return err
+}
+
+func (b *sqlBackend) Get(bucket, key string) ([]byte, error) {
+	q := fmt.Sprintf(`SELECT value FROM kv WHERE bucket = %s AND key = %s`,
+		b.placeholder(1), b.placeholder(2))
+
+	var value []byte
+	err := b.db.QueryRow(q, bucket, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+func (b *sqlBackend) Delete(bucket, key string) error {
+	q := fmt.Sprintf(`DELETE FROM kv WHERE bucket = %s AND key = %s`,
+		b.placeholder(1), b.placeholder(2))
+
+	res, err := b.db.Exec(q, bucket, key)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (b *sqlBackend) Scan(bucket string) ([]Entry, error) {
+	q := fmt.Sprintf(`SELECT key, value FROM kv WHERE bucket = %s`, b.placeholder(1))
+
+	rows, err := b.db.Query(q, bucket)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(&e.Key, &e.Value); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+// Update runs fn under a single *sql.Tx, so every Get/Put/Delete/ForEach
+// call fn makes commits (or rolls back) together.
+func (b *sqlBackend) Update(fn func(Txn) error) error {
+	tx, err := b.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := fn(&sqlTxn{tx: tx, placeholder: b.placeholder}); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// View runs fn under a single read-only *sql.Tx.
+func (b *sqlBackend) View(fn func(Txn) error) error {
+	tx, err := b.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	return fn(&sqlTxn{tx: tx, placeholder: b.placeholder})
+}
+
+// sqlRow is the gob wire format used by Backup/Restore: a full copy of
+// the kv table, one row per entry.
+type sqlRow struct {
+	Bucket string
+	Key    string
+	Value  []byte
+}
+
+// Backup writes every row of the kv table to w, gob-encoded.
+func (b *sqlBackend) Backup(w io.Writer) error {
+	rows, err := b.db.Query(`SELECT bucket, key, value FROM kv`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	enc := gob.NewEncoder(w)
+	for rows.Next() {
+		var r sqlRow
+		if err := rows.Scan(&r.Bucket, &r.Key, &r.Value); err != nil {
+			return err
+		}
+		if err := enc.Encode(&r); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// Restore replaces the kv table's contents with the rows read from r,
+// which must have been produced by Backup.
+func (b *sqlBackend) Restore(r io.Reader) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	tx, err := b.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM kv`); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	q := fmt.Sprintf(`
+		INSERT INTO kv (bucket, key, value, updated_at)
+		VALUES (%s, %s, %s, %s)
+	`, b.placeholder(1), b.placeholder(2), b.placeholder(3), b.placeholder(4))
+
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	now := time.Now()
+	for {
+		var row sqlRow
+		if err := dec.Decode(&row); err == io.EOF {
+			break
+		} else if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if _, err := tx.Exec(q, row.Bucket, row.Key, row.Value, now); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (b *sqlBackend) Close() error {
+	return b.db.Close()
+}
+
+// sqlTxn implements Txn on top of a single *sql.Tx, mirroring KVStore's
+// gob encode/decode convention.
+type sqlTxn struct {
+	tx          *sql.Tx
+	placeholder func(n int) string
+}
+
+func (t *sqlTxn) Get(bucket, key string, value interface{}) error {
+	q := fmt.Sprintf(`SELECT value FROM kv WHERE bucket = %s AND key = %s`,
+		t.placeholder(1), t.placeholder(2))
+
+	var v []byte
+	err := t.tx.QueryRow(q, bucket, key).Scan(&v)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if v == nil || value == nil {
+		return nil
+	}
+
+	return gob.NewDecoder(bytes.NewReader(v)).Decode(value)
+}
+
+func (t *sqlTxn) Put(bucket, key string, value interface{}) error {
+	if value == nil {
+		return ErrBadValue
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+		return err
+	}
+
+	q := fmt.Sprintf(`
+		INSERT INTO kv (bucket, key, value, updated_at)
+		VALUES (%s, %s, %s, %s)
+		ON CONFLICT (bucket, key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at
+	`, t.placeholder(1), t.placeholder(2), t.placeholder(3), t.placeholder(4))
+
+	_, err := t.tx.Exec(q, bucket, key, buf.Bytes(), time.Now())
+	return err
+}
+
+func (t *sqlTxn) Delete(bucket, key string) error {
+	q := fmt.Sprintf(`DELETE FROM kv WHERE bucket = %s AND key = %s`,
+		t.placeholder(1), t.placeholder(2))
+
+	res, err := t.tx.Exec(q, bucket, key)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (t *sqlTxn) ForEach(bucket string, fn func(key string, value []byte) error) error {
+	q := fmt.Sprintf(`SELECT key, value FROM kv WHERE bucket = %s`, t.placeholder(1))
+
+	rows, err := t.tx.Query(q, bucket)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var value []byte
+		if err := rows.Scan(&key, &value); err != nil {
+			return err
+		}
+		if err := fn(key, value); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"net/url"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// newSQLiteBackend opens a sqlite-backed Backend, e.g. "sqlite:///path/to/file.db".
+func newSQLiteBackend(u *url.URL) (Backend, error) {
+	path := u.Path
+	if path == "" {
+		path = u.Opaque
+	}
+
+	return openSQLBackend("sqlite3", path, func(n int) string {
+		return "?"
+	})
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io"
+	"sync"
+)
+
+// fakeBackend is a minimal in-memory Backend, used only by tests so
+// KVStore/labels logic can be exercised without touching disk.
+type fakeBackend struct {
+	mu      sync.Mutex
+	buckets map[string]map[string][]byte
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{buckets: make(map[string]map[string][]byte)}
+}
+
+func (f *fakeBackend) AddBucket(bucket string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.buckets[bucket] == nil {
+		f.buckets[bucket] = make(map[string][]byte)
+	}
+	return nil
+}
+
+func (f *fakeBackend) Put(bucket, key string, value []byte) error {
+	if err := f.AddBucket(bucket); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.buckets[bucket][key] = append([]byte(nil), value...)
+	return nil
+}
+
+func (f *fakeBackend) Get(bucket, key string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bkt, ok := f.buckets[bucket]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	v, ok := bkt[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return v, nil
+}
+
+func (f *fakeBackend) Delete(bucket, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bkt, ok := f.buckets[bucket]
+	if !ok {
+		return ErrNotFound
+	}
+	if _, ok := bkt[key]; !ok {
+		return ErrNotFound
+	}
+	delete(bkt, key)
+	return nil
+}
+
+func (f *fakeBackend) Scan(bucket string) ([]Entry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var entries []Entry
+	for k, v := range f.buckets[bucket] {
+		entries = append(entries, Entry{Key: k, Value: v})
+	}
+	return entries, nil
+}
+
+func (f *fakeBackend) Update(fn func(Txn) error) error {
+	return fn(&fakeTxn{f: f})
+}
+
+func (f *fakeBackend) View(fn func(Txn) error) error {
+	return fn(&fakeTxn{f: f})
+}
+
+func (f *fakeBackend) Backup(w io.Writer) error  { return nil }
+func (f *fakeBackend) Restore(r io.Reader) error { return nil }
+func (f *fakeBackend) Close() error              { return nil }
+
+// fakeTxn implements Txn directly against fakeBackend's maps, gob-encoding
+// values the same way boltTxn does.
+type fakeTxn struct {
+	f *fakeBackend
+}
+
+func (t *fakeTxn) Get(bucket, key string, value interface{}) error {
+	v, err := t.f.Get(bucket, key)
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		return nil
+	}
+	return gob.NewDecoder(bytes.NewReader(v)).Decode(value)
+}
+
+func (t *fakeTxn) Put(bucket, key string, value interface{}) error {
+	if value == nil {
+		return ErrBadValue
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+		return err
+	}
+	return t.f.Put(bucket, key, buf.Bytes())
+}
+
+func (t *fakeTxn) Delete(bucket, key string) error {
+	return t.f.Delete(bucket, key)
+}
+
+func (t *fakeTxn) ForEach(bucket string, fn func(key string, value []byte) error) error {
+	entries, err := t.f.Scan(bucket)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := fn(e.Key, e.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
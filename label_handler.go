@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// labelHit is a single FindByLabels result, exposed as plain JSON like
+// PasteHit is for /search.
+type labelHit struct {
+	Key string `json:"key"`
+}
+
+// labelsHandler serves GET /labels?syntax=...&matched=...&mode=greedy,
+// running every query parameter other than "mode" as a label=value pair
+// against kvs.FindByLabels over pastesBucket and returning the matching
+// paste keys as JSON. mode defaults to an exact label-set match; pass
+// mode=greedy to match pastes that carry the queried labels plus others.
+func labelsHandler(kvs *KVStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		mode := MatchExact
+		if query.Get("mode") == "greedy" {
+			mode = MatchGreedy
+		}
+		query.Del("mode")
+
+		if len(query) == 0 {
+			http.Error(w, "at least one label parameter is required", http.StatusBadRequest)
+			return
+		}
+
+		match := make(map[string]string, len(query))
+		for k := range query {
+			match[k] = query.Get(k)
+		}
+
+		entries, err := kvs.FindByLabels(pastesBucket, match, mode)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		hits := make([]labelHit, 0, len(entries))
+		for _, e := range entries {
+			hits = append(hits, labelHit{Key: e.Key})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(hits)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+)
+
+// MatchMode controls how FindByLabels compares an entry's labels against
+// the query in a FindByLabels call.
+type MatchMode int
+
+const (
+	// MatchExact requires an entry's full label set to equal the query.
+	MatchExact MatchMode = iota
+
+	// MatchGreedy requires only that the query be a subset of an
+	// entry's labels; the entry may carry additional labels besides.
+	MatchGreedy
+)
+
+// labelsBucket is the secondary bucket backing bucket's label index: it
+// maps "labelKey=labelVal" to the gob-encoded set of entry keys in bucket
+// that carry that label.
+func labelsBucket(bucket string) string {
+	return bucket + "__labels"
+}
+
+// entryLabelsBucket maps an entry key back to the full label set it was
+// last stored with, so Delete and re-PutWithLabels know which labelsBucket
+// sets to scrub without having to scan the whole index.
+func entryLabelsBucket(bucket string) string {
+	return bucket + "__entry_labels"
+}
+
+func labelPair(k, v string) string {
+	return fmt.Sprintf("%s=%s", k, v)
+}
+
+// PutWithLabels stores value under key in bucket, like Put, and also tags
+// it with labels so it can later be found by FindByLabels without
+// scanning every entry in bucket.
+func (kvs *KVStore) PutWithLabels(bucket, key string, value interface{}, labels map[string]string) error {
+	if err := kvs.unindexLabels(bucket, key); err != nil {
+		return err
+	}
+
+	if err := kvs.Put(bucket, key, value); err != nil {
+		return err
+	}
+
+	if err := kvs.Put(entryLabelsBucket(bucket), key, labels); err != nil {
+		return err
+	}
+
+	for k, v := range labels {
+		if err := kvs.addToLabelSet(bucket, labelPair(k, v), key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FindByLabels returns every entry in bucket whose labels match the query
+// according to mode.
+func (kvs *KVStore) FindByLabels(bucket string, match map[string]string, mode MatchMode) ([]Entry, error) {
+	if len(match) == 0 {
+		return nil, nil
+	}
+
+	var candidates map[string]struct{}
+	for k, v := range match {
+		set, err := kvs.labelSet(bucket, labelPair(k, v))
+		if err != nil {
+			return nil, err
+		}
+
+		if candidates == nil {
+			candidates = set
+			continue
+		}
+		for key := range candidates {
+			if _, ok := set[key]; !ok {
+				delete(candidates, key)
+			}
+		}
+	}
+
+	var entries []Entry
+	for key := range candidates {
+		if mode == MatchExact {
+			var labels map[string]string
+			if err := kvs.Get(entryLabelsBucket(bucket), key, &labels); err != nil {
+				return nil, err
+			}
+			if len(labels) != len(match) {
+				continue
+			}
+		}
+
+		value, err := kvs.backend.Get(bucket, key)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, Entry{Key: key, Value: value})
+	}
+
+	return entries, nil
+}
+
+// unindexLabels removes key from every labelsBucket set it currently
+// belongs to, and drops its entryLabelsBucket record. It is a no-op if key
+// was never labeled.
+func (kvs *KVStore) unindexLabels(bucket, key string) error {
+	var labels map[string]string
+	err := kvs.Get(entryLabelsBucket(bucket), key, &labels)
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for k, v := range labels {
+		if err := kvs.removeFromLabelSet(bucket, labelPair(k, v), key); err != nil {
+			return err
+		}
+	}
+
+	if err := kvs.backend.Delete(entryLabelsBucket(bucket), key); err != nil && err != ErrNotFound {
+		return err
+	}
+
+	return nil
+}
+
+func (kvs *KVStore) labelSet(bucket, pair string) (map[string]struct{}, error) {
+	v, err := kvs.backend.Get(labelsBucket(bucket), pair)
+	if err == ErrNotFound {
+		return map[string]struct{}{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	set := map[string]struct{}{}
+	if err := gob.NewDecoder(bytes.NewReader(v)).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	return set, nil
+}
+
+func (kvs *KVStore) addToLabelSet(bucket, pair, key string) error {
+	set, err := kvs.labelSet(bucket, pair)
+	if err != nil {
+		return err
+	}
+
+	set[key] = struct{}{}
+
+	return kvs.Put(labelsBucket(bucket), pair, set)
+}
+
+func (kvs *KVStore) removeFromLabelSet(bucket, pair, key string) error {
+	set, err := kvs.labelSet(bucket, pair)
+	if err != nil {
+		return err
+	}
+
+	delete(set, key)
+
+	if len(set) == 0 {
+		err := kvs.backend.Delete(labelsBucket(bucket), pair)
+		if err == ErrNotFound {
+			return nil
+		}
+		return err
+	}
+
+	return kvs.Put(labelsBucket(bucket), pair, set)
+}
@@ -0,0 +1,61 @@
+package main
+
+import "testing"
+
+func newTestKVStore() *KVStore {
+	return &KVStore{backend: newFakeBackend()}
+}
+
+func TestFindByLabelsMatchExact(t *testing.T) {
+	kvs := newTestKVStore()
+
+	if err := kvs.PutWithLabels("pastes", "p1", "hello", map[string]string{"syntax": "python", "matched": "aws_key"}); err != nil {
+		t.Fatalf("PutWithLabels(p1): %v", err)
+	}
+	if err := kvs.PutWithLabels("pastes", "p2", "world", map[string]string{"syntax": "python"}); err != nil {
+		t.Fatalf("PutWithLabels(p2): %v", err)
+	}
+
+	entries, err := kvs.FindByLabels("pastes", map[string]string{"syntax": "python"}, MatchExact)
+	if err != nil {
+		t.Fatalf("FindByLabels: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Key != "p2" {
+		t.Fatalf("MatchExact: expected only p2, got %+v", entries)
+	}
+}
+
+func TestFindByLabelsMatchGreedy(t *testing.T) {
+	kvs := newTestKVStore()
+
+	kvs.PutWithLabels("pastes", "p1", "hello", map[string]string{"syntax": "python", "matched": "aws_key"})
+	kvs.PutWithLabels("pastes", "p2", "world", map[string]string{"syntax": "python"})
+
+	entries, err := kvs.FindByLabels("pastes", map[string]string{"syntax": "python"}, MatchGreedy)
+	if err != nil {
+		t.Fatalf("FindByLabels: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("MatchGreedy: expected both entries, got %+v", entries)
+	}
+}
+
+func TestDeleteScrubsLabelIndex(t *testing.T) {
+	kvs := newTestKVStore()
+
+	if err := kvs.PutWithLabels("pastes", "p1", "hello", map[string]string{"syntax": "python"}); err != nil {
+		t.Fatalf("PutWithLabels: %v", err)
+	}
+
+	if err := kvs.Delete("pastes", "p1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	entries, err := kvs.FindByLabels("pastes", map[string]string{"syntax": "python"}, MatchGreedy)
+	if err != nil {
+		t.Fatalf("FindByLabels: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected label index scrubbed after Delete, got %+v", entries)
+	}
+}
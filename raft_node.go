@@ -0,0 +1,370 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/coreos/etcd/raft"
+	"github.com/coreos/etcd/raft/raftpb"
+)
+
+// raftOpKind identifies which operation a raftOp carries. Reads are always
+// served from the local Bolt store; of KVStore's writes, only the seen-paste
+// dedup records actually go through Raft today (via MarkSeen) - general
+// Put/Delete calls (including PutWithLabels/unindexLabels) still hit the
+// backend directly and are not cluster-replicated.
+type raftOpKind int
+
+const (
+	raftOpMarkSeen raftOpKind = iota
+	raftOpPastesBatch
+)
+
+// seenBucket holds the MarkSeen dedup records shared by every node in the
+// cluster, replacing the single-process conf.keys map.
+const seenBucket = "seen"
+
+// pastesBatchBucket is the Bucket used by raftOpPastesBatch proposals; it
+// is never actually written to the KV store, it just namespaces the op so
+// apply() can tell it apart from a real MarkSeen.
+const pastesBatchBucket = "pastes-batch"
+
+// raftOp is a single KVStore mutation, gob-encoded and shipped through
+// proposeC/commitC so every node in the cluster applies the same
+// mutations in the same order.
+type raftOp struct {
+	Kind   raftOpKind
+	Bucket string
+	Key    string
+	Value  []byte
+}
+
+// raftNode wraps KVStore behind a Raft state machine, modeled on the etcd
+// raftexample: proposals enter on proposeC, are committed in cluster order
+// by Raft, and are applied to the local Bolt store as they arrive on
+// commitC. Reads never go through Raft and are served directly from kvs.
+//
+// Scope: this only makes the seen-paste dedup bucket and leader election
+// highly available. The paste archive itself (what PutWithLabels writes
+// and FindByLabels queries) is sharded across nodes by shardOf, not
+// replicated - each node only ever holds the pastes its own shard
+// downloaded. Losing a node loses that node's share of the archive; only
+// dedup state and the ability to elect a new leader survive a node loss.
+type raftNode struct {
+	id      uint64
+	peers   []string
+	kvs     *KVStore
+	buckets []string
+
+	proposeC chan raftOp
+	commitC  chan raftOp
+	errorC   chan error
+
+	node      raft.Node
+	storage   *raft.MemoryStorage
+	transport *raftTransport
+
+	snapshotter   *raftSnapshotter
+	confState     raftpb.ConfState
+	appliedIndex  uint64
+	snapshotIndex uint64
+	snapCount     uint64
+
+	// isLeader is written by run() on every SoftState change and read by
+	// IsLeader() from the scrape()/main goroutine, so it goes through
+	// atomic rather than a plain bool.
+	isLeader int32
+
+	stopc chan struct{}
+}
+
+// newRaftNode starts (or rejoins) a Raft cluster member for id among
+// peers, replaying any existing snapshot in snapdir first. The returned
+// raftNode applies committed ops to kvs's local backend as they arrive on
+// commitC; see raftOpKind for which of kvs's writes are actually routed
+// through it versus called on kvs directly.
+func newRaftNode(id uint64, peers []string, kvs *KVStore, buckets []string, snapdir string) (*raftNode, error) {
+	rn := &raftNode{
+		id:        id,
+		peers:     peers,
+		kvs:       kvs,
+		buckets:   buckets,
+		proposeC:  make(chan raftOp),
+		commitC:   make(chan raftOp),
+		errorC:    make(chan error),
+		storage:   raft.NewMemoryStorage(),
+		transport: newRaftTransport(id, peers),
+		snapCount: 10000,
+		stopc:     make(chan struct{}),
+	}
+
+	snapshotter, err := newRaftSnapshotter(snapdir)
+	if err != nil {
+		return nil, err
+	}
+	rn.snapshotter = snapshotter
+
+	if snapshot, err := snapshotter.Load(); err == nil {
+		if err := rn.storage.ApplySnapshot(*snapshot); err != nil {
+			return nil, err
+		}
+		rn.confState = snapshot.Metadata.ConfState
+		rn.appliedIndex = snapshot.Metadata.Index
+		rn.snapshotIndex = snapshot.Metadata.Index
+		rn.replaySnapshot(snapshot)
+	}
+
+	rpeers := make([]raft.Peer, len(peers))
+	for i := range peers {
+		rpeers[i] = raft.Peer{ID: uint64(i + 1)}
+	}
+
+	c := &raft.Config{
+		ID:              id,
+		ElectionTick:    10,
+		HeartbeatTick:   1,
+		Storage:         rn.storage,
+		MaxSizePerMsg:   1024 * 1024,
+		MaxInflightMsgs: 256,
+	}
+
+	if rn.appliedIndex > 0 {
+		rn.node = raft.RestartNode(c)
+	} else {
+		rn.node = raft.StartNode(c, rpeers)
+	}
+
+	rn.transport.receive = rn.step
+	rn.transport.register()
+
+	go rn.run()
+
+	return rn, nil
+}
+
+// Propose submits op to the Raft log. It returns once Raft has accepted
+// the proposal, not once it has committed; the caller should read commitC
+// (or rely on the run loop driving kvs directly, as scrape() does) to
+// observe the applied result.
+func (rn *raftNode) Propose(op raftOp) {
+	rn.proposeC <- op
+}
+
+// IsLeader reports whether this node is the current Raft leader. Only the
+// leader should call the Pastebin API on a given scrape tick.
+func (rn *raftNode) IsLeader() bool {
+	return atomic.LoadInt32(&rn.isLeader) != 0
+}
+
+// CommitC returns the channel of ops applied to this node's state machine,
+// in cluster-wide commit order.
+func (rn *raftNode) CommitC() <-chan raftOp {
+	return rn.commitC
+}
+
+// MarkSeen proposes that key be recorded as seen, for cluster-wide paste
+// dedup in place of the single-process conf.keys map.
+func (rn *raftNode) MarkSeen(key string) {
+	var buf bytes.Buffer
+	gob.NewEncoder(&buf).Encode(time.Now())
+
+	rn.Propose(raftOp{Kind: raftOpMarkSeen, Bucket: seenBucket, Key: key, Value: buf.Bytes()})
+}
+
+// ProposePastes proposes the leader's freshly-fetched paste list to the
+// cluster so every follower learns about it (and can process its shard)
+// without ever calling the Pastebin API itself.
+func (rn *raftNode) ProposePastes(data []byte) {
+	rn.Propose(raftOp{Kind: raftOpPastesBatch, Bucket: pastesBatchBucket, Value: data})
+}
+
+func (rn *raftNode) step(msg raftpb.Message) error {
+	return rn.node.Step(context.Background(), msg)
+}
+
+func (rn *raftNode) run() {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case op := <-rn.proposeC:
+			var buf bytes.Buffer
+			if err := gob.NewEncoder(&buf).Encode(op); err != nil {
+				log.Printf("[-] raft: could not encode proposal: %s.\n", err.Error())
+				continue
+			}
+			if err := rn.node.Propose(context.Background(), buf.Bytes()); err != nil {
+				log.Printf("[-] raft: could not propose: %s.\n", err.Error())
+			}
+
+		case <-ticker.C:
+			rn.node.Tick()
+
+		case rd := <-rn.node.Ready():
+			if rd.SoftState != nil {
+				var leader int32
+				if rd.SoftState.Lead == rn.id {
+					leader = 1
+				}
+				atomic.StoreInt32(&rn.isLeader, leader)
+			}
+
+			rn.storage.Append(rd.Entries)
+			rn.transport.send(rd.Messages)
+
+			if !raft.IsEmptySnap(rd.Snapshot) {
+				rn.storage.ApplySnapshot(rd.Snapshot)
+				rn.confState = rd.Snapshot.Metadata.ConfState
+				rn.snapshotIndex = rd.Snapshot.Metadata.Index
+				rn.appliedIndex = rd.Snapshot.Metadata.Index
+
+				if err := rn.snapshotter.Save(rd.Snapshot); err != nil {
+					log.Printf("[-] raft: could not save received snapshot: %s.\n", err.Error())
+				}
+				rn.replaySnapshot(&rd.Snapshot)
+			}
+
+			rn.applyEntries(rd.CommittedEntries)
+			rn.maybeSnapshot()
+
+			rn.node.Advance()
+
+		case <-rn.stopc:
+			rn.node.Stop()
+			return
+		}
+	}
+}
+
+// applyEntries decodes each committed raft log entry back into a raftOp
+// and applies it to the local Bolt store via a single KVStore call, then
+// forwards it on commitC for anyone (e.g. the scrape loop's dedup logic)
+// that wants to observe applied mutations.
+func (rn *raftNode) applyEntries(entries []raftpb.Entry) {
+	for _, entry := range entries {
+		rn.appliedIndex = entry.Index
+
+		if entry.Type != raftpb.EntryNormal || len(entry.Data) == 0 {
+			continue
+		}
+
+		var op raftOp
+		if err := gob.NewDecoder(bytes.NewReader(entry.Data)).Decode(&op); err != nil {
+			log.Printf("[-] raft: could not decode committed entry: %s.\n", err.Error())
+			continue
+		}
+
+		rn.apply(op)
+
+		select {
+		case rn.commitC <- op:
+		case <-rn.stopc:
+			return
+		}
+	}
+}
+
+func (rn *raftNode) apply(op raftOp) {
+	var err error
+	switch op.Kind {
+	case raftOpMarkSeen:
+		err = rn.kvs.backend.Put(op.Bucket, op.Key, op.Value)
+	case raftOpPastesBatch:
+		// Not a KV mutation: nothing to apply to the Bolt store. The
+		// decoded paste list is picked up from commitC by the scrape
+		// loop instead, see main()'s cluster.commitC goroutine.
+	}
+	if err != nil && err != ErrNotFound {
+		log.Printf("[-] raft: could not apply committed entry: %s.\n", err.Error())
+	}
+}
+
+// maybeSnapshot triggers a snapshot and log compaction once snapCount
+// entries have been applied since the last one, keeping the in-memory
+// Raft log (and restart replay time) bounded.
+func (rn *raftNode) maybeSnapshot() {
+	if rn.appliedIndex-rn.snapshotIndex <= rn.snapCount {
+		return
+	}
+
+	data, err := rn.snapshotData()
+	if err != nil {
+		log.Printf("[-] raft: could not snapshot KVStore: %s.\n", err.Error())
+		return
+	}
+
+	snapshot, err := rn.storage.CreateSnapshot(rn.appliedIndex, &rn.confState, data)
+	if err != nil {
+		log.Printf("[-] raft: could not create snapshot: %s.\n", err.Error())
+		return
+	}
+
+	if err := rn.snapshotter.Save(snapshot); err != nil {
+		log.Printf("[-] raft: could not save snapshot: %s.\n", err.Error())
+		return
+	}
+
+	compactIndex := rn.snapshotIndex
+	if rn.appliedIndex > rn.snapCount {
+		compactIndex = rn.appliedIndex - rn.snapCount
+	}
+	rn.storage.Compact(compactIndex)
+
+	rn.snapshotIndex = rn.appliedIndex
+}
+
+// snapshotData gob-encodes every entry in rn.buckets, giving a full copy
+// of the KV state this node is responsible for replicating.
+func (rn *raftNode) snapshotData() ([]byte, error) {
+	state := make(map[string][]Entry, len(rn.buckets))
+	for _, bucket := range rn.buckets {
+		entries, err := rn.kvs.backend.Scan(bucket)
+		if err != nil {
+			return nil, err
+		}
+		state[bucket] = entries
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(state); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// replaySnapshot installs a snapshot produced by snapshotData into the
+// local Bolt store, catching this node's replica up to the snapshot's
+// index without replaying every individual Raft entry that preceded it.
+func (rn *raftNode) replaySnapshot(snapshot *raftpb.Snapshot) {
+	if len(snapshot.Data) == 0 {
+		return
+	}
+
+	var state map[string][]Entry
+	if err := gob.NewDecoder(bytes.NewReader(snapshot.Data)).Decode(&state); err != nil {
+		log.Printf("[-] raft: could not decode snapshot: %s.\n", err.Error())
+		return
+	}
+
+	for bucket, entries := range state {
+		if err := rn.kvs.backend.AddBucket(bucket); err != nil {
+			log.Printf("[-] raft: could not recreate bucket %s: %s.\n", bucket, err.Error())
+			continue
+		}
+		for _, e := range entries {
+			if err := rn.kvs.backend.Put(bucket, e.Key, e.Value); err != nil {
+				log.Printf("[-] raft: could not restore %s/%s: %s.\n", bucket, e.Key, err.Error())
+			}
+		}
+	}
+}
+
+func (rn *raftNode) Stop() {
+	close(rn.stopc)
+}
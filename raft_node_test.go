@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+	"time"
+
+	"github.com/coreos/etcd/raft/raftpb"
+)
+
+// newTestRaftNode builds a raftNode with just enough wired up to exercise
+// apply/applyEntries directly, without starting an actual raft.Node - the
+// Raft protocol itself is coreos/etcd/raft's problem, not this package's.
+func newTestRaftNode() (*raftNode, *KVStore) {
+	kvs := newTestKVStore()
+	return &raftNode{
+		kvs:     kvs,
+		commitC: make(chan raftOp, 8),
+		stopc:   make(chan struct{}),
+	}, kvs
+}
+
+func encodeOp(t *testing.T, op raftOp) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(op); err != nil {
+		t.Fatalf("encode op: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestApplyMarkSeenWritesToBackend(t *testing.T) {
+	rn, kvs := newTestRaftNode()
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(time.Now()); err != nil {
+		t.Fatalf("encode timestamp: %v", err)
+	}
+
+	rn.apply(raftOp{Kind: raftOpMarkSeen, Bucket: seenBucket, Key: "abc123", Value: buf.Bytes()})
+
+	var seenAt time.Time
+	if err := kvs.Get(seenBucket, "abc123", &seenAt); err != nil {
+		t.Fatalf("expected raftOpMarkSeen to be applied to the backend: %v", err)
+	}
+}
+
+func TestApplyPastesBatchLeavesBackendUntouched(t *testing.T) {
+	rn, kvs := newTestRaftNode()
+
+	rn.apply(raftOp{Kind: raftOpPastesBatch, Bucket: pastesBatchBucket, Value: []byte("batch")})
+
+	entries, err := kvs.backend.Scan(pastesBatchBucket)
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected raftOpPastesBatch to leave the KV store untouched, got %+v", entries)
+	}
+}
+
+func TestApplyEntriesForwardsOnCommitC(t *testing.T) {
+	rn, _ := newTestRaftNode()
+
+	entry := raftpb.Entry{
+		Type:  raftpb.EntryNormal,
+		Index: 1,
+		Data:  encodeOp(t, raftOp{Kind: raftOpPastesBatch, Bucket: pastesBatchBucket, Value: []byte("batch")}),
+	}
+
+	rn.applyEntries([]raftpb.Entry{entry})
+
+	select {
+	case op := <-rn.commitC:
+		if op.Kind != raftOpPastesBatch {
+			t.Fatalf("expected raftOpPastesBatch on commitC, got %v", op.Kind)
+		}
+	default:
+		t.Fatalf("expected an op on commitC after applyEntries")
+	}
+
+	if rn.appliedIndex != 1 {
+		t.Fatalf("expected appliedIndex to advance to 1, got %d", rn.appliedIndex)
+	}
+}
+
+func TestApplyEntriesSkipsConfChangeAndEmptyEntries(t *testing.T) {
+	rn, kvs := newTestRaftNode()
+
+	entries := []raftpb.Entry{
+		{Type: raftpb.EntryConfChange, Index: 1, Data: []byte("irrelevant")},
+		{Type: raftpb.EntryNormal, Index: 2, Data: nil},
+	}
+
+	rn.applyEntries(entries)
+
+	select {
+	case op := <-rn.commitC:
+		t.Fatalf("expected no op on commitC, got %+v", op)
+	default:
+	}
+
+	if rn.appliedIndex != 2 {
+		t.Fatalf("expected appliedIndex to advance to 2, got %d", rn.appliedIndex)
+	}
+
+	entries2, err := kvs.backend.Scan(seenBucket)
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if len(entries2) != 0 {
+		t.Fatalf("expected nothing applied to the backend, got %+v", entries2)
+	}
+}
+
+func TestIsLeaderReflectsSoftState(t *testing.T) {
+	rn, _ := newTestRaftNode()
+	rn.id = 1
+
+	if rn.IsLeader() {
+		t.Fatalf("expected IsLeader to be false before any SoftState update")
+	}
+
+	rn.isLeader = 1
+	if !rn.IsLeader() {
+		t.Fatalf("expected IsLeader to be true once isLeader is set")
+	}
+}
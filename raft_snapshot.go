@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+
+	"github.com/coreos/etcd/raft/raftpb"
+	"github.com/coreos/etcd/snap"
+)
+
+// raftSnapshotter persists Raft snapshots (gob-encoded KV state, see
+// raftNode.snapshotData) to snapdir, so a restarted node can catch up
+// without replaying its entire log history.
+type raftSnapshotter struct {
+	snap *snap.Snapshotter
+}
+
+func newRaftSnapshotter(snapdir string) (*raftSnapshotter, error) {
+	if err := os.MkdirAll(snapdir, 0750); err != nil {
+		return nil, err
+	}
+
+	return &raftSnapshotter{snap: snap.New(snapdir)}, nil
+}
+
+// Save writes snapshot to disk.
+func (s *raftSnapshotter) Save(snapshot raftpb.Snapshot) error {
+	return s.snap.SaveSnap(snapshot)
+}
+
+// Load reads back the most recent snapshot written by Save, if any.
+func (s *raftSnapshotter) Load() (*raftpb.Snapshot, error) {
+	return s.snap.Load()
+}
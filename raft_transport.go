@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"log"
+	"net/http"
+
+	"github.com/coreos/etcd/raft/raftpb"
+)
+
+// raftTransport ships raftpb.Messages between cluster peers over plain
+// HTTP, gob-encoded. It is deliberately simpler than etcd's own
+// rafthttp: this scraper cluster is small and long-lived, so point-to-
+// point POSTs with no connection pooling or snapshot streaming are
+// enough.
+type raftTransport struct {
+	id      uint64
+	peers   []string
+	receive func(raftpb.Message) error
+}
+
+func newRaftTransport(id uint64, peers []string) *raftTransport {
+	return &raftTransport{id: id, peers: peers}
+}
+
+// register wires t's HTTP handler in. Callers must only invoke it once
+// t.receive has been set: a message arriving before then would call a nil
+// t.receive from handleMessage and panic.
+func (t *raftTransport) register() {
+	http.HandleFunc("/raft/message", t.handleMessage)
+}
+
+func (t *raftTransport) send(messages []raftpb.Message) {
+	for _, msg := range messages {
+		if int(msg.To) < 1 || int(msg.To) > len(t.peers) {
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(msg); err != nil {
+			log.Printf("[-] raft: could not encode message to %d: %s.\n", msg.To, err.Error())
+			continue
+		}
+
+		peer := t.peers[msg.To-1]
+		go func(peer string, body []byte) {
+			resp, err := http.Post("http://"+peer+"/raft/message", "application/octet-stream", bytes.NewReader(body))
+			if err != nil {
+				log.Printf("[-] raft: could not reach peer %s: %s.\n", peer, err.Error())
+				return
+			}
+			resp.Body.Close()
+		}(peer, buf.Bytes())
+	}
+}
+
+func (t *raftTransport) handleMessage(w http.ResponseWriter, r *http.Request) {
+	var msg raftpb.Message
+	if err := gob.NewDecoder(r.Body).Decode(&msg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := t.receive(msg); err != nil {
+		log.Printf("[-] raft: could not step message from %d: %s.\n", msg.From, err.Error())
+	}
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"bytes"
+	"encoding/gob"
 	"encoding/json"
+	"hash/fnv"
 	"io/ioutil"
 	"log"
 	"net/http"
@@ -9,14 +12,57 @@ import (
 )
 
 var conf Config
+var kvs *KVStore
+var pasteIndex *PasteIndex
+var cluster *raftNode
 
-func cleanKeys() {
+// cleanKeys expires stale entries from the seen-paste dedup bucket. It
+// must be called from inside a KVStore.Update transaction so that, for a
+// given tick, expiry and the new-key inserts in markSeen land in the same
+// atomic write.
+func cleanKeys(tx Txn, now time.Time) error {
+	var expired []string
+
+	err := tx.ForEach(seenBucket, func(key string, value []byte) error {
+		var seenAt time.Time
+		if err := gob.NewDecoder(bytes.NewReader(value)).Decode(&seenAt); err != nil {
+			return err
+		}
+		if now.Sub(seenAt) > conf.maxTime {
+			expired = append(expired, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, key := range expired {
+		if err := tx.Delete(seenBucket, key); err != nil && err != ErrNotFound {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// markSeen records that every paste in pastes has now been processed, and
+// expires stale entries, all in the single KVStore.Update transaction for
+// this tick.
+func markSeen(pastes []*Paste) {
 	now := time.Now()
 
-	for key, _ := range conf.keys {
-		if now.Sub(conf.keys[key]) > conf.maxTime {
-			delete(conf.keys, key)
+	err := kvs.Update(func(tx Txn) error {
+		for i, _ := range pastes {
+			if err := tx.Put(seenBucket, pastes[i].Key, now); err != nil {
+				return err
+			}
 		}
+
+		return cleanKeys(tx, now)
+	})
+	if err != nil {
+		log.Printf("[-] Could not update key store: %s.\n", err.Error())
 	}
 }
 
@@ -42,7 +88,77 @@ func get(url string) []byte {
 	return body
 }
 
+// shardOf hashes key to a shard in [0, shards), so every cluster node can
+// independently agree on who downloads and processes a given paste.
+func shardOf(key string, shards int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(shards))
+}
+
+// pastesBucket is the KVStore bucket processShard tags every processed
+// paste into via PutWithLabels, so it can later be found with FindByLabels
+// (exposed over HTTP by labelsHandler) without scanning the whole archive.
+const pastesBucket = "pastes"
+
+// processShard downloads and processes every paste assigned to shard out
+// of shards (all of them, if this node is running standalone). Dedup
+// bookkeeping is the caller's job: standalone mode folds it into the
+// single per-tick KVStore.Update in markSeen, cluster mode proposes it
+// through Raft so every replica's seen-bucket stays in sync.
+func processShard(pastes []*Paste, shard, shards int) {
+	for i, _ := range pastes {
+		p := pastes[i]
+		if shards > 1 && shardOf(p.Key, shards) != shard {
+			continue
+		}
+
+		p.Download()
+		p.Process()
+
+		if cluster != nil {
+			cluster.MarkSeen(p.Key)
+		}
+
+		if err := pasteIndex.Index(p); err != nil {
+			log.Printf("[-] Could not index paste %s.\n", p.Key)
+		}
+
+		labels := map[string]string{"syntax": p.Syntax}
+		if err := kvs.PutWithLabels(pastesBucket, p.Key, p, labels); err != nil {
+			log.Printf("[-] Could not tag paste %s: %s.\n", p.Key, err.Error())
+		}
+	}
+}
+
+// expireSeenLocally runs cleanKeys against this node's local store only,
+// without proposing the expiry through Raft: every node already applied
+// the same MarkSeen timestamps from the commit log, so each can
+// independently decide what its own clock now considers stale.
+func expireSeenLocally() {
+	err := kvs.Update(func(tx Txn) error {
+		return cleanKeys(tx, time.Now())
+	})
+	if err != nil {
+		log.Printf("[-] Could not expire seen-paste bucket: %s.\n", err.Error())
+	}
+}
+
+// scrape fetches the latest public pastes and processes them. In cluster
+// mode, only the Raft leader calls the Pastebin API; it proposes the
+// resulting paste list to the cluster so every follower learns about it
+// and processes its own shard, instead of hitting the API itself. Every
+// node, leader or follower, expires its own copy of the seen bucket each
+// tick regardless.
 func scrape() {
+	if cluster != nil {
+		expireSeenLocally()
+	}
+
+	if cluster != nil && !cluster.IsLeader() {
+		return
+	}
+
 	var pastes []*Paste
 
 	log.Println("[+] Checking for new pastes.")
@@ -56,18 +172,66 @@ func scrape() {
 		return
 	}
 
-	for i, _ := range pastes {
-		p := pastes[i]
-		p.Download()
-		p.Process()
+	if cluster == nil {
+		processShard(pastes, 0, 1)
+		markSeen(pastes)
+		return
 	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(pastes); err != nil {
+		log.Printf("[-] Could not encode paste list for the cluster: %s.\n", err.Error())
+		return
+	}
+	cluster.ProposePastes(buf.Bytes())
 }
 
 func main() {
 	conf = newConfig()
+
+	var err error
+	kvs, err = NewKVStore(conf.kvURI)
+	if err != nil {
+		log.Fatalf("[-] Could not open key-value store: %s.\n", err.Error())
+	}
+	defer kvs.Close()
+
+	pasteIndex, err = NewPasteIndex(conf.dataDir + "/search.bleve")
+	if err != nil {
+		log.Fatalf("[-] Could not open search index: %s.\n", err.Error())
+	}
+	defer pasteIndex.Close()
+
+	http.HandleFunc("/search", searchHandler(pasteIndex))
+	http.HandleFunc("/labels", labelsHandler(kvs))
+	go http.ListenAndServe(conf.searchAddr, nil)
+
+	if len(conf.peers) > 0 {
+		var err error
+		cluster, err = newRaftNode(conf.nodeID, conf.peers, kvs, []string{seenBucket}, conf.dataDir+"/raft-snap")
+		if err != nil {
+			log.Fatalf("[-] Could not start cluster: %s.\n", err.Error())
+		}
+
+		go func() {
+			for op := range cluster.CommitC() {
+				if op.Kind != raftOpPastesBatch {
+					continue
+				}
+
+				var pastes []*Paste
+				if err := gob.NewDecoder(bytes.NewReader(op.Value)).Decode(&pastes); err != nil {
+					log.Printf("[-] Could not decode cluster paste batch: %s.\n", err.Error())
+					continue
+				}
+
+				processShard(pastes, int(conf.nodeID-1), len(conf.peers))
+			}
+		}()
+	}
+
 	for {
 		scrape()
 		time.Sleep(conf.sleep)
-		cleanKeys()
 	}
 }
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestShardOfIsDeterministicAndInRange(t *testing.T) {
+	const shards = 4
+
+	for _, key := range []string{"abc", "def", "ghi", "paste-1234"} {
+		first := shardOf(key, shards)
+		if second := shardOf(key, shards); first != second {
+			t.Fatalf("shardOf(%q) not deterministic: %d != %d", key, first, second)
+		}
+		if first < 0 || first >= shards {
+			t.Fatalf("shardOf(%q) = %d, want [0,%d)", key, first, shards)
+		}
+	}
+}
+
+func TestShardOfDistributesAcrossShards(t *testing.T) {
+	const shards = 4
+
+	seen := make(map[int]bool)
+	for i := 0; i < 200; i++ {
+		seen[shardOf(fmt.Sprintf("paste-%d", i), shards)] = true
+	}
+
+	if len(seen) != shards {
+		t.Fatalf("expected keys to land in all %d shards, got %d: %+v", shards, len(seen), seen)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/blevesearch/bleve"
+)
+
+// PasteIndex is a full-text index over scraped pastes, built on top of
+// bleve. It is stored as a boltdb-backed index alongside the KVStore's
+// Bolt file, so a single scraper node keeps both its dedup state and its
+// search index in the same data directory.
+type PasteIndex struct {
+	index bleve.Index
+}
+
+// PasteHit is a single search result: the indexed paste's key plus the
+// fields bleve considered relevant to the query.
+type PasteHit struct {
+	Key   string  `json:"key"`
+	Score float64 `json:"score"`
+}
+
+// pasteDoc is what actually gets indexed for each paste. Keeping it
+// separate from Paste lets the index mapping evolve (or drop fields)
+// without touching the scraping code.
+type pasteDoc struct {
+	Title   string `json:"title"`
+	Syntax  string `json:"syntax"`
+	User    string `json:"user"`
+	Size    string `json:"size"`
+	Date    string `json:"date"`
+	Content string `json:"content"`
+}
+
+// NewPasteIndex opens (or creates) a bleve index at path, using boltdb as
+// its storage backend.
+func NewPasteIndex(path string) (*PasteIndex, error) {
+	index, err := bleve.Open(path)
+	if err == bleve.ErrorIndexPathDoesNotExist {
+		mapping := bleve.NewIndexMapping()
+		index, err = bleve.NewUsingMapping(path, mapping, bleve.Config.DefaultIndexType, "boltdb")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &PasteIndex{index: index}, nil
+}
+
+// Index adds or updates p in the search index, keyed on p.Key.
+func (pi *PasteIndex) Index(p *Paste) error {
+	doc := pasteDoc{
+		Title:   p.Title,
+		Syntax:  p.Syntax,
+		User:    p.User,
+		Size:    p.Size,
+		Date:    p.Date,
+		Content: p.Content,
+	}
+
+	return pi.index.Index(p.Key, doc)
+}
+
+// Search runs a bleve query-string query against the index, returning up
+// to limit hits ordered by score. Field filters (e.g. "syntax:python") can
+// be embedded directly in query using bleve's query string syntax.
+func (pi *PasteIndex) Search(query string, limit int) ([]*PasteHit, error) {
+	req := bleve.NewSearchRequest(bleve.NewQueryStringQuery(query))
+	req.Size = limit
+
+	result, err := pi.index.Search(req)
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]*PasteHit, 0, len(result.Hits))
+	for _, h := range result.Hits {
+		hits = append(hits, &PasteHit{Key: h.ID, Score: h.Score})
+	}
+
+	return hits, nil
+}
+
+// Delete removes the paste with the given key from the index.
+func (pi *PasteIndex) Delete(key string) error {
+	return pi.index.Delete(key)
+}
+
+// Close closes the underlying bleve index.
+func (pi *PasteIndex) Close() error {
+	return pi.index.Close()
+}
+
+// searchQuery builds the bleve query string for a /search request, folding
+// the optional syntax filter into the free-text query q.
+func searchQuery(q, syntax string) string {
+	if syntax == "" {
+		return q
+	}
+
+	return fmt.Sprintf("%s +syntax:%s", q, syntax)
+}
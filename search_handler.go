@@ -0,0 +1,28 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// searchHandler serves GET /search?q=...&syntax=..., running q (plus the
+// optional syntax filter) against pasteIndex and returning the hits as
+// JSON.
+func searchHandler(idx *PasteIndex) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query().Get("q")
+		if q == "" {
+			http.Error(w, "missing q parameter", http.StatusBadRequest)
+			return
+		}
+
+		hits, err := idx.Search(searchQuery(q, r.URL.Query().Get("syntax")), 20)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(hits)
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+// Txn groups several KVStore operations together so a backend can run them
+// under a single underlying transaction, instead of each Get/Put/Delete
+// opening (and committing) its own.
+type Txn interface {
+	// Get decodes the value stored under key in bucket into value, which
+	// must be a pointer-typed. It returns ErrNotFound if key is absent.
+	Get(bucket, key string, value interface{}) error
+
+	// Put gob-encodes value and stores it under key in bucket.
+	Put(bucket, key string, value interface{}) error
+
+	// Delete removes key from bucket. It returns ErrNotFound if key is
+	// absent.
+	Delete(bucket, key string) error
+
+	// ForEach calls fn for every key/value pair currently in bucket. The
+	// value passed to fn is the raw gob-encoded blob; fn can decode it
+	// itself if it needs the original Go value.
+	ForEach(bucket string, fn func(key string, value []byte) error) error
+}